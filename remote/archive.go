@@ -0,0 +1,60 @@
+// Copyright 2022 Jetpack Technologies Inc and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package remote
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// archiveEntries writes entries (paths relative to root) into w as a
+// gzip-compressed tar. Entries are written in the order given, so callers
+// that want a reproducible archive should pass them pre-sorted; mtimes are
+// zeroed so the same inputs always produce byte-identical output.
+func archiveEntries(w io.Writer, root string, entries []Entry) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+
+	for _, e := range entries {
+		if err := addEntry(tw, root, e); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("close tar writer: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		return fmt.Errorf("close gzip writer: %v", err)
+	}
+	return nil
+}
+
+func addEntry(tw *tar.Writer, root string, e Entry) error {
+	path := filepath.Join(root, filepath.FromSlash(e.Path))
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("open %s: %v", path, err)
+	}
+	defer f.Close()
+
+	hdr := &tar.Header{
+		Name:    e.Path,
+		Mode:    int64(e.Mode.Perm()),
+		Size:    e.Size,
+		ModTime: time.Unix(0, 0),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("write tar header for %s: %v", e.Path, err)
+	}
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("write tar contents for %s: %v", e.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 Jetpack Technologies Inc and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package remote
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArchiveEntriesDeterministic(t *testing.T) {
+	dir := writeTestTree(t)
+	manifest, err := BuildManifest(dir)
+	if err != nil {
+		t.Fatalf("BuildManifest: %v", err)
+	}
+
+	var buf1, buf2 bytes.Buffer
+	if err := archiveEntries(&buf1, dir, manifest.Entries); err != nil {
+		t.Fatalf("archiveEntries: %v", err)
+	}
+	if err := archiveEntries(&buf2, dir, manifest.Entries); err != nil {
+		t.Fatalf("archiveEntries (again): %v", err)
+	}
+
+	if !bytes.Equal(buf1.Bytes(), buf2.Bytes()) {
+		t.Errorf("archiveEntries produced different output for the same input across two calls")
+	}
+}
+
+func TestArchiveEntriesPreservesModeAndContents(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "exe")
+	if err := os.WriteFile(path, []byte("hello"), 0755); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	manifest, err := BuildManifest(dir)
+	if err != nil {
+		t.Fatalf("BuildManifest: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := archiveEntries(&buf, dir, manifest.Entries); err != nil {
+		t.Fatalf("archiveEntries: %v", err)
+	}
+
+	gr, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("gzip.NewReader: %v", err)
+	}
+	tr := tar.NewReader(gr)
+
+	hdr, err := tr.Next()
+	if err != nil {
+		t.Fatalf("tar.Next: %v", err)
+	}
+	if hdr.Name != "exe" {
+		t.Errorf("hdr.Name = %q, want %q", hdr.Name, "exe")
+	}
+	if hdr.FileInfo().Mode().Perm()&0111 == 0 {
+		t.Errorf("hdr mode = %v, want exec bits preserved", hdr.FileInfo().Mode())
+	}
+
+	contents, err := io.ReadAll(tr)
+	if err != nil {
+		t.Fatalf("read tar contents: %v", err)
+	}
+	if string(contents) != "hello" {
+		t.Errorf("contents = %q, want %q", contents, "hello")
+	}
+}
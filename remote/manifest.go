@@ -0,0 +1,99 @@
+// Copyright 2022 Jetpack Technologies Inc and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package remote packages a devbox environment and streams it to a remote
+// host over SSH, similar in spirit to how gomote push snapshots a local
+// tree and ships it to a remote builder. The sender lives next to the
+// shell package because it needs shell.Shell's generated init file to
+// bootstrap the remote side.
+package remote
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Entry describes a single file tracked by a Manifest.
+type Entry struct {
+	Path string
+	SHA1 string
+	Size int64
+	Mode os.FileMode
+}
+
+// Manifest lists every file that makes up a devbox environment, in
+// deterministic (path-sorted) order, so two manifests built from the same
+// inputs always compare equal.
+type Manifest struct {
+	Entries []Entry
+}
+
+// BuildManifest walks root and hashes every regular file it finds.
+func BuildManifest(root string) (*Manifest, error) {
+	var entries []Entry
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		sum, err := sha1File(path)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, Entry{
+			Path: filepath.ToSlash(rel),
+			SHA1: sum,
+			Size: info.Size(),
+			Mode: info.Mode(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("build manifest for %s: %v", root, err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+	return &Manifest{Entries: entries}, nil
+}
+
+func sha1File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// Missing returns the entries in m that aren't present, by path and SHA1,
+// in have. This is the cheap checksum handshake that lets Push skip files
+// the remote already has.
+func (m *Manifest) Missing(have *Manifest) []Entry {
+	known := make(map[string]string, len(have.Entries))
+	for _, e := range have.Entries {
+		known[e.Path] = e.SHA1
+	}
+
+	var missing []Entry
+	for _, e := range m.Entries {
+		if known[e.Path] != e.SHA1 {
+			missing = append(missing, e)
+		}
+	}
+	return missing
+}
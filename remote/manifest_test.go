@@ -0,0 +1,106 @@
+// Copyright 2022 Jetpack Technologies Inc and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package remote
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestTree(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"bin/tool":       "#!/bin/sh\necho hi\n",
+		"lib/helper.so":  "binary-contents",
+		"share/readme.md": "docs",
+	}
+	for rel, contents := range files {
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("mkdir for %s: %v", rel, err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0755); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+	return dir
+}
+
+func TestBuildManifestSortedAndDeterministic(t *testing.T) {
+	dir := writeTestTree(t)
+
+	m1, err := BuildManifest(dir)
+	if err != nil {
+		t.Fatalf("BuildManifest: %v", err)
+	}
+	m2, err := BuildManifest(dir)
+	if err != nil {
+		t.Fatalf("BuildManifest (again): %v", err)
+	}
+
+	if len(m1.Entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(m1.Entries))
+	}
+	for i := 1; i < len(m1.Entries); i++ {
+		if m1.Entries[i-1].Path >= m1.Entries[i].Path {
+			t.Errorf("entries not sorted by path: %q >= %q", m1.Entries[i-1].Path, m1.Entries[i].Path)
+		}
+	}
+
+	if len(m1.Entries) != len(m2.Entries) {
+		t.Fatalf("two BuildManifest calls on the same tree gave different entry counts: %d vs %d", len(m1.Entries), len(m2.Entries))
+	}
+	for i := range m1.Entries {
+		if m1.Entries[i] != m2.Entries[i] {
+			t.Errorf("entry %d differs across calls: %+v vs %+v", i, m1.Entries[i], m2.Entries[i])
+		}
+	}
+}
+
+func TestBuildManifestCapturesModeAndSize(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "exe")
+	if err := os.WriteFile(path, []byte("hello"), 0755); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	m, err := BuildManifest(dir)
+	if err != nil {
+		t.Fatalf("BuildManifest: %v", err)
+	}
+	if len(m.Entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(m.Entries))
+	}
+
+	e := m.Entries[0]
+	if e.Size != 5 {
+		t.Errorf("Size = %d, want 5", e.Size)
+	}
+	if e.Mode.Perm()&0111 == 0 {
+		t.Errorf("Mode = %v, want the exec bits preserved", e.Mode)
+	}
+}
+
+func TestManifestMissing(t *testing.T) {
+	have := &Manifest{Entries: []Entry{
+		{Path: "a", SHA1: "same"},
+		{Path: "b", SHA1: "stale"},
+	}}
+	want := &Manifest{Entries: []Entry{
+		{Path: "a", SHA1: "same"},  // unchanged, not missing
+		{Path: "b", SHA1: "fresh"}, // changed, missing
+		{Path: "c", SHA1: "new"},   // new, missing
+	}}
+
+	missing := want.Missing(have)
+	if len(missing) != 2 {
+		t.Fatalf("got %d missing entries, want 2: %+v", len(missing), missing)
+	}
+	if missing[0].Path != "b" || missing[1].Path != "c" {
+		t.Errorf("missing = %+v, want entries b and c", missing)
+	}
+}
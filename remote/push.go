@@ -0,0 +1,200 @@
+// Copyright 2022 Jetpack Technologies Inc and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package remote
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+
+	"go.jetpack.io/devbox/debug"
+)
+
+// remoteStoreDir is where pushed entries land on the remote host. It's
+// written as "$HOME/..." rather than "~/..." because the commands below
+// interpolate it inside double quotes, where POSIX shells expand $HOME
+// but never perform tilde expansion.
+const remoteStoreDir = "$HOME/.devbox/store"
+
+// shellQuote wraps s in single quotes for safe interpolation into a
+// command string that's handed to a remote shell, escaping any embedded
+// single quotes. Entry paths come from walking an arbitrary local
+// directory tree (see BuildManifest) and can't be trusted not to contain
+// shell metacharacters: %q only escapes Go string syntax, not shell
+// syntax, and leaves things like $(...) or backticks free to expand on
+// the remote side even inside double quotes.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// PushOptions configures a Push.
+type PushOptions struct {
+	// InitFile is the generated shell init file (see shell.Shell's
+	// buildInitFile) used to bootstrap the remote shell once the
+	// environment has landed.
+	InitFile string
+
+	// DryRun, if true, prints what would be transferred instead of
+	// transferring it.
+	DryRun bool
+}
+
+// Push packages the devbox environment rooted at storeDir into
+// deterministic tar.gz archives, one per entry, and streams them to host
+// over SSH, skipping any entry the remote already has. It then runs
+// opts.InitFile on the remote side (see bootstrapRemote).
+//
+// Nothing in this repository calls Push; its caller supplies storeDir,
+// holding the resolved Nix store closure, and the init file it built for
+// the local shell.
+func Push(host, storeDir string, opts PushOptions) error {
+	manifest, err := BuildManifest(storeDir)
+	if err != nil {
+		return err
+	}
+
+	if err := hasRemoteNix(host); err != nil {
+		debug.Log("Remote %s has no Nix, falling back to a static bootstrap: %v", host, err)
+		if opts.DryRun {
+			printDryRun(manifest.Entries)
+			return nil
+		}
+		return pushStaticBootstrap(host, storeDir, manifest, opts.InitFile)
+	}
+
+	remoteManifest, err := fetchRemoteManifest(host)
+	if err != nil {
+		debug.Log("Failed to fetch manifest from %s, assuming it has nothing: %v", host, err)
+		remoteManifest = &Manifest{}
+	}
+
+	missing := manifest.Missing(remoteManifest)
+	if opts.DryRun {
+		printDryRun(missing)
+		return nil
+	}
+
+	if len(missing) > 0 {
+		if err := uploadEntries(host, storeDir, missing); err != nil {
+			return err
+		}
+	}
+	return bootstrapRemote(host, opts.InitFile)
+}
+
+func printDryRun(entries []Entry) {
+	for _, e := range entries {
+		fmt.Printf("would push %s (%d bytes)\n", e.Path, e.Size)
+	}
+}
+
+// pushStaticBootstrap ships the whole store, not just the entries the
+// remote is missing, since without Nix the remote has no way to resolve
+// the closure itself.
+func pushStaticBootstrap(host, storeDir string, manifest *Manifest, initFile string) error {
+	if err := uploadEntries(host, storeDir, manifest.Entries); err != nil {
+		return err
+	}
+	return bootstrapRemote(host, initFile)
+}
+
+// uploadEntries streams each missing entry to host in parallel.
+func uploadEntries(host, storeDir string, missing []Entry) error {
+	g, ctx := errgroup.WithContext(context.Background())
+	for _, e := range missing {
+		e := e
+		g.Go(func() error {
+			return uploadEntry(ctx, host, storeDir, e)
+		})
+	}
+	return g.Wait()
+}
+
+// uploadEntry tars up a single entry and pipes it into a remote `tar -xz`
+// rooted at remoteStoreDir.
+func uploadEntry(ctx context.Context, host, storeDir string, e Entry) error {
+	buf := &bytes.Buffer{}
+	if err := archiveEntries(buf, storeDir, []Entry{e}); err != nil {
+		return err
+	}
+
+	// e.Path's directory component comes straight out of BuildManifest's
+	// walk of an arbitrary local tree, so it has to be shell-quoted, not
+	// just Go-%q-escaped, before it's handed to the remote shell.
+	relDir := filepath.ToSlash(filepath.Dir(e.Path))
+	cmd := exec.CommandContext(ctx, "ssh", host,
+		fmt.Sprintf(`mkdir -p "%s"/%s && tar -xzC "%s"`, remoteStoreDir, shellQuote(relDir), remoteStoreDir))
+	cmd.Stdin = buf
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("push %s to %s: %v", e.Path, host, err)
+	}
+	return nil
+}
+
+// fetchRemoteManifest asks host for the SHA1 of every file it already has
+// in remoteStoreDir, so Push can skip re-sending them. It cds into
+// remoteStoreDir and finds paths relative to it ("./foo" rather than the
+// expanded absolute directory), so the result doesn't depend on knowing
+// what $HOME expanded to on the remote.
+func fetchRemoteManifest(host string) (*Manifest, error) {
+	cmd := exec.Command("ssh", host,
+		fmt.Sprintf(`cd "%s" 2>/dev/null && find . -type f -exec sha1sum {} + || true`, remoteStoreDir))
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("query manifest on %s: %v", host, err)
+	}
+	return parseSha1sum(string(out)), nil
+}
+
+// parseSha1sum turns `sha1sum`'s "<hash>  <path>" output, with paths
+// relative to remoteStoreDir (e.g. "./foo"), into a Manifest. Size isn't
+// known from this output, but Manifest.Missing only compares path and
+// SHA1, so it's left unset.
+func parseSha1sum(output string) *Manifest {
+	var entries []Entry
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		rel := strings.TrimPrefix(fields[1], "./")
+		entries = append(entries, Entry{Path: rel, SHA1: fields[0]})
+	}
+	return &Manifest{Entries: entries}
+}
+
+// hasRemoteNix reports whether host has a `nix` binary on its PATH.
+func hasRemoteNix(host string) error {
+	if err := exec.Command("ssh", host, "command -v nix").Run(); err != nil {
+		return fmt.Errorf("nix not found on %s: %v", host, err)
+	}
+	return nil
+}
+
+// bootstrapRemote streams initFile's contents to host and runs them as a
+// script with a non-interactive `sh`. It does not launch an interactive
+// shell the way shell.Shell.ExecCommand does: the remote shell exits once
+// the script finishes.
+func bootstrapRemote(host, initFile string) error {
+	script, err := os.ReadFile(initFile)
+	if err != nil {
+		return fmt.Errorf("read init file %s: %v", initFile, err)
+	}
+
+	cmd := exec.Command("ssh", host, "sh")
+	cmd.Stdin = bytes.NewReader(script)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("bootstrap remote shell on %s: %v", host, err)
+	}
+	return nil
+}
@@ -0,0 +1,272 @@
+// Copyright 2022 Jetpack Technologies Inc and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+// Package envpath durably manages entries in the user's PATH across shell
+// restarts. Unlike shell.ExecCommand, which only affects the environment of
+// the single shell it launches, entries added here persist because they're
+// installed via the user's rcfiles and survive opening a brand new terminal.
+//
+// This package is only the library half of that story: there is no
+// `devbox add`/`devbox rm` command in this slice of the repo yet to call
+// Add/Remove, so shell-out entries don't actually survive across sessions
+// until a caller wires one in.
+package envpath
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	configDirName = "devbox"
+	pathDirName   = "path.d"
+	loadShName    = "load.sh"
+	loadFishName  = "load.fish"
+)
+
+// slashEscape is substituted for path separators when turning a PATH entry
+// into a filename, so Add/Remove/Has can round-trip an entry by name alone
+// instead of having to parse file contents.
+const slashEscape = "%2F"
+
+// devboxConfigDir returns $XDG_CONFIG_HOME/devbox, falling back to
+// ~/.config/devbox.
+func devboxConfigDir() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, configDirName), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", configDirName), nil
+}
+
+// pathDir returns the directory containing one *.env file per managed PATH
+// entry.
+func pathDir() (string, error) {
+	dir, err := devboxConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, pathDirName), nil
+}
+
+func encodeEntry(entry string) string {
+	return strings.ReplaceAll(entry, string(filepath.Separator), slashEscape)
+}
+
+func decodeEntry(name string) string {
+	return strings.ReplaceAll(name, slashEscape, string(filepath.Separator))
+}
+
+func entryFile(entry string) (string, error) {
+	dir, err := pathDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, encodeEntry(entry)+".env"), nil
+}
+
+// Paths returns the PATH entries currently managed by this package, sorted
+// lexically by their on-disk encoding.
+func Paths() ([]string, error) {
+	dir, err := pathDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".env" {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(e.Name(), ".env"))
+	}
+	sort.Strings(names)
+
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = decodeEntry(name)
+	}
+	return paths, nil
+}
+
+// Has reports whether entry is already managed.
+func Has(entry string) bool {
+	file, err := entryFile(entry)
+	if err != nil {
+		return false
+	}
+	_, err = os.Stat(file)
+	return err == nil
+}
+
+// Add durably appends entry to the user's PATH. It reports false if entry
+// was already present. The first call also installs the loader that sources
+// managed entries into the user's shells; see ensureLoaders.
+func Add(entry string) (bool, error) {
+	if Has(entry) {
+		return false, nil
+	}
+
+	dir, err := pathDir()
+	if err != nil {
+		return false, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, fmt.Errorf("create path.d directory: %v", err)
+	}
+	if err := ensureLoaders(); err != nil {
+		return false, err
+	}
+
+	file, err := entryFile(entry)
+	if err != nil {
+		return false, err
+	}
+	script := fmt.Sprintf("export PATH=\"$PATH:%s\"\n", entry)
+	if err := os.WriteFile(file, []byte(script), 0644); err != nil {
+		return false, fmt.Errorf("write path entry: %v", err)
+	}
+	return true, nil
+}
+
+// Remove durably removes entry from the user's PATH. It reports false if
+// entry wasn't managed.
+func Remove(entry string) (bool, error) {
+	if !Has(entry) {
+		return false, nil
+	}
+	file, err := entryFile(entry)
+	if err != nil {
+		return false, err
+	}
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return false, fmt.Errorf("remove path entry: %v", err)
+	}
+	return true, nil
+}
+
+type rcfile struct {
+	path       string
+	loaderLine string
+}
+
+// rcfiles lists the rcfile and one-line loader snippet for every shell
+// whose PATH we manage durably. bash, zsh, and ksh can all source the same
+// POSIX load.sh; fish needs its own load.fish because it can't source
+// POSIX `export VAR=value` syntax.
+func rcfiles() ([]rcfile, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := devboxConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	loadSh := filepath.Join(cfg, loadShName)
+	loadFish := filepath.Join(cfg, loadFishName)
+
+	posixLoader := fmt.Sprintf(`[ -s "%s" ] && source "%s"`, loadSh, loadSh)
+	return []rcfile{
+		{path: filepath.Join(home, ".bashrc"), loaderLine: posixLoader},
+		{path: filepath.Join(home, ".zshrc"), loaderLine: posixLoader},
+		{path: filepath.Join(home, ".kshrc"), loaderLine: posixLoader},
+		{
+			path:       filepath.Join(home, ".config", "fish", "config.fish"),
+			loaderLine: fmt.Sprintf(`test -s "%s"; and source "%s"`, loadFish, loadFish),
+		},
+	}, nil
+}
+
+// ensureLoaders writes the load.sh/load.fish scripts and makes sure every
+// rcfile in rcfiles sources the appropriate one, so that entries added here
+// keep showing up in every new shell the user opens.
+func ensureLoaders() error {
+	if err := writeLoadScripts(); err != nil {
+		return err
+	}
+	rcs, err := rcfiles()
+	if err != nil {
+		return err
+	}
+	for _, rc := range rcs {
+		if err := ensureLoaderLine(rc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeLoadScripts() error {
+	dir, err := pathDir()
+	if err != nil {
+		return err
+	}
+	cfg, err := devboxConfigDir()
+	if err != nil {
+		return err
+	}
+
+	loadSh := fmt.Sprintf(`#!/bin/sh
+# Generated by devbox. Sources every durable PATH entry managed by
+# go.jetpack.io/devbox/shell/envpath.
+for x in "%s"/*.env; do
+	[ -r "$x" ] && . "$x"
+done
+unset x
+`, dir)
+	if err := os.WriteFile(filepath.Join(cfg, loadShName), []byte(loadSh), 0644); err != nil {
+		return fmt.Errorf("write load.sh: %v", err)
+	}
+
+	loadFish := fmt.Sprintf(`# Generated by devbox. Sources every durable PATH entry managed by
+# go.jetpack.io/devbox/shell/envpath.
+for x in %s/*.env
+	set -l entry (string replace -r '^export PATH="\$PATH:(.*)"$' '$1' < $x)
+	if test -n "$entry"
+		fish_add_path -g $entry
+	end
+end
+`, dir)
+	if err := os.WriteFile(filepath.Join(cfg, loadFishName), []byte(loadFish), 0644); err != nil {
+		return fmt.Errorf("write load.fish: %v", err)
+	}
+	return nil
+}
+
+func ensureLoaderLine(rc rcfile) error {
+	content, err := os.ReadFile(rc.path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read rcfile %s: %v", rc.path, err)
+	}
+	if strings.Contains(string(content), rc.loaderLine) {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(rc.path), 0755); err != nil {
+		return fmt.Errorf("create directory for rcfile %s: %v", rc.path, err)
+	}
+	f, err := os.OpenFile(rc.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open rcfile %s: %v", rc.path, err)
+	}
+	defer f.Close()
+
+	if _, err := fmt.Fprintf(f, "\n# Added by devbox\n%s\n", rc.loaderLine); err != nil {
+		return fmt.Errorf("update rcfile %s: %v", rc.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 Jetpack Technologies Inc and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package envpath
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddRemoveHasRoundTrip(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+
+	entry := filepath.Join(home, "bin")
+
+	if Has(entry) {
+		t.Fatalf("Has(%q) = true before Add", entry)
+	}
+
+	added, err := Add(entry)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if !added {
+		t.Errorf("Add(%q) = false, want true on first call", entry)
+	}
+	if !Has(entry) {
+		t.Errorf("Has(%q) = false after Add", entry)
+	}
+
+	paths, err := Paths()
+	if err != nil {
+		t.Fatalf("Paths: %v", err)
+	}
+	if len(paths) != 1 || paths[0] != entry {
+		t.Errorf("Paths() = %v, want [%q]", paths, entry)
+	}
+
+	// Adding the same entry again should be a no-op.
+	added, err = Add(entry)
+	if err != nil {
+		t.Fatalf("Add (again): %v", err)
+	}
+	if added {
+		t.Errorf("Add(%q) = true on second call, want false", entry)
+	}
+
+	removed, err := Remove(entry)
+	if err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if !removed {
+		t.Errorf("Remove(%q) = false, want true", entry)
+	}
+	if Has(entry) {
+		t.Errorf("Has(%q) = true after Remove", entry)
+	}
+
+	// Removing an already-removed entry should be a no-op, not an error.
+	removed, err = Remove(entry)
+	if err != nil {
+		t.Fatalf("Remove (again): %v", err)
+	}
+	if removed {
+		t.Errorf("Remove(%q) = true on second call, want false", entry)
+	}
+}
+
+func TestAddInstallsLoaders(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+
+	if _, err := Add(filepath.Join(home, "bin")); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	cfg := filepath.Join(home, ".config", "devbox")
+	for _, name := range []string{loadShName, loadFishName} {
+		if _, err := os.Stat(filepath.Join(cfg, name)); err != nil {
+			t.Errorf("expected %s to be written: %v", name, err)
+		}
+	}
+
+	bashrc, err := os.ReadFile(filepath.Join(home, ".bashrc"))
+	if err != nil {
+		t.Fatalf("read .bashrc: %v", err)
+	}
+	if !strings.Contains(string(bashrc), "load.sh") {
+		t.Errorf(".bashrc missing the devbox loader line:\n%s", bashrc)
+	}
+}
@@ -24,6 +24,8 @@ const (
 	shZsh     name = "zsh"
 	shKsh     name = "ksh"
 	shPosix   name = "posix"
+	shFish    name = "fish"
+	shPwsh    name = "pwsh"
 )
 
 // Shell configures a user's shell to run in Devbox.
@@ -39,6 +41,13 @@ type Shell struct {
 	// The script's environment will contain an ORIGINAL_PATH environment
 	// variable, which will bet set to the PATH before the shell's init
 	// files have had a chance to modify it.
+	//
+	// PreInitHook is folded into its own hook fragment (see
+	// AddHookFragment), rewritten each time the init file is built, so it keeps
+	// running even though hooks.d is now the source of truth for what
+	// sources into the init file. New callers with more than one hook to
+	// register should use AddHookFragment directly instead, since separate
+	// fragments compose across plugins instead of clobbering one another.
 	PreInitHook string
 
 	// PostInitHook contains commands that will run after the user's init
@@ -82,6 +91,12 @@ func Detect() (*Shell, error) {
 		if sh.initFile == "" {
 			sh.initFile = ".shinit"
 		}
+	case "fish":
+		sh.name = shFish
+		sh.initFile = fishConfigPath()
+	case "pwsh", "powershell":
+		sh.name = shPwsh
+		sh.initFile = pwshProfilePath()
 	default:
 		sh.name = shUnknown
 	}
@@ -101,20 +116,148 @@ func rcfilePath(basename string) string {
 	return filepath.Join(home, basename)
 }
 
+// configHomePath returns the user's XDG config directory, honoring
+// XDG_CONFIG_HOME and falling back to ~/.config.
+func configHomePath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return dir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config")
+}
+
+// hooksDir returns the directory where per-plugin/package shell hook
+// fragments are stored, keyed by shell flavor (see AddHookFragment).
+func hooksDir() string {
+	return filepath.Join(configHomePath(), "devbox", "hooks.d")
+}
+
+// hookExt returns the file extension hook fragments use for this shell's
+// flavor.
+func (s *Shell) hookExt() string {
+	switch s.name {
+	case shFish:
+		return ".fish"
+	case shPwsh:
+		return ".ps1"
+	default:
+		return ".sh"
+	}
+}
+
+// legacyPreInitHookFragment is the fragment name Shell.PreInitHook is
+// folded into. The "00-" prefix keeps it sorting ahead of fragments added
+// via AddHookFragment, matching PreInitHook's old pre-init ordering.
+const legacyPreInitHookFragment = "00-legacy-pre-init-hook"
+
+// AddHookFragment writes script to a file named name in the hooks.d
+// directory, so every devbox shell of this flavor sources it at startup.
+// Giving each plugin or package its own fragment file makes hooks from
+// multiple packages composable, instead of clobbering a single
+// PreInitHook string.
+func (s *Shell) AddHookFragment(name, script string) error {
+	dir := hooksDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("create hooks.d directory: %v", err)
+	}
+	path := filepath.Join(dir, name+s.hookExt())
+	if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+		return fmt.Errorf("write hook fragment %s: %v", name, err)
+	}
+	return nil
+}
+
+// RemoveHookFragment removes the hook fragment previously written by
+// AddHookFragment under name, if any. It is not an error to remove a
+// fragment that doesn't exist.
+func (s *Shell) RemoveHookFragment(name string) error {
+	path := filepath.Join(hooksDir(), name+s.hookExt())
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("remove hook fragment %s: %v", name, err)
+	}
+	return nil
+}
+
+// hasHookFragments reports whether any hook fragments have been written
+// for this shell's flavor.
+func (s *Shell) hasHookFragments() bool {
+	matches, err := filepath.Glob(filepath.Join(hooksDir(), "*"+s.hookExt()))
+	return err == nil && len(matches) > 0
+}
+
+// hookLoopSnippet returns the POSIX shell snippet that sources every hook
+// fragment in hooks.d, in lexical order, mirroring the envman/load.sh
+// pattern.
+func hookLoopSnippet(ext string) string {
+	return fmt.Sprintf(`for x in "%s"/*%s; do
+	[ -r "$x" ] && . "$x"
+done
+unset x`, hooksDir(), ext)
+}
+
+// fishHookLoopSnippet is hookLoopSnippet's fish equivalent.
+func fishHookLoopSnippet(ext string) string {
+	return fmt.Sprintf(`for x in %s/*%s
+	source $x
+end`, hooksDir(), ext)
+}
+
+// pwshHookLoopSnippet is hookLoopSnippet's PowerShell equivalent. fish and
+// PowerShell can't parse POSIX `for ... do ... done` syntax at all, so
+// each flavor needs its own loop, not just its own file extension.
+func pwshHookLoopSnippet(ext string) string {
+	return fmt.Sprintf(`Get-ChildItem -Path "%s" -Filter "*%s" | Sort-Object Name | ForEach-Object { . $_.FullName }`,
+		hooksDir(), ext)
+}
+
+// fishConfigPath returns the absolute path to the user's fish config file.
+// It doesn't guarantee that the file exists.
+func fishConfigPath() string {
+	return filepath.Join(configHomePath(), "fish", "config.fish")
+}
+
+// pwshProfilePath returns the absolute path to the user's PowerShell
+// profile, honoring $PROFILE when pwsh has already set it. It doesn't
+// guarantee that the file exists.
+func pwshProfilePath() string {
+	if profile := os.Getenv("PROFILE"); profile != "" {
+		return profile
+	}
+	return filepath.Join(configHomePath(), "powershell", "Microsoft.PowerShell_profile.ps1")
+}
+
 func (s *Shell) buildInitFile() ([]byte, error) {
-	prehook := strings.TrimSpace(s.PreInitHook)
+	if prehook := strings.TrimSpace(s.PreInitHook); prehook != "" {
+		if err := s.AddHookFragment(legacyPreInitHookFragment, prehook); err != nil {
+			return nil, fmt.Errorf("fold PreInitHook into a hook fragment: %v", err)
+		}
+	}
+
 	posthook := strings.TrimSpace(s.PostInitHook)
-	if prehook == "" && posthook == "" {
+	hasFragments := s.hasHookFragments()
+	if !hasFragments && posthook == "" {
 		return nil, nil
 	}
 
+	// fish and PowerShell's syntax are different enough from the POSIX
+	// shells above that they need their own templates.
+	switch s.name {
+	case shFish:
+		return s.buildFishInitFile(posthook, hasFragments)
+	case shPwsh:
+		return s.buildPwshInitFile(posthook, hasFragments)
+	}
+
 	buf := bytes.Buffer{}
-	if prehook != "" {
+	if hasFragments {
 		buf.WriteString(`
 # Begin Devbox Pre-init Hook
 
 `)
-		buf.WriteString(prehook)
+		buf.WriteString(hookLoopSnippet(s.hookExt()))
 		buf.WriteString(`
 
 # End Devbox Pre-init Hook
@@ -152,6 +295,107 @@ func (s *Shell) buildInitFile() ([]byte, error) {
 	return b, nil
 }
 
+// buildFishInitFile assembles a fish-flavored version of the devbox init
+// file. fish doesn't support an rcfile flag, so the generated file inlines
+// the contents of the user's existing config.fish, and it uses `set -x` rather than
+// POSIX `VAR=value` syntax to export ORIGINAL_PATH.
+func (s *Shell) buildFishInitFile(posthook string, hasFragments bool) ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteString("set -x ORIGINAL_PATH $PATH\n")
+
+	if hasFragments {
+		buf.WriteString(`
+# Begin Devbox Pre-init Hook
+
+`)
+		buf.WriteString(fishHookLoopSnippet(s.hookExt()))
+		buf.WriteString(`
+
+# End Devbox Pre-init Hook
+
+`)
+	}
+
+	// The fish config file is allowed to not exist yet; there's just
+	// nothing to source in that case.
+	if initFile, err := os.ReadFile(s.initFile); err == nil {
+		initFile = bytes.TrimSpace(initFile)
+		if len(initFile) > 0 {
+			buf.Write(initFile)
+			buf.WriteString("\n")
+		}
+	}
+
+	if posthook != "" {
+		buf.WriteString(`
+# Begin Devbox Post-init Hook
+
+`)
+		buf.WriteString(posthook)
+		buf.WriteString(`
+
+# End Devbox Post-init Hook`)
+	}
+
+	b := buf.Bytes()
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil, nil
+	}
+	b = append(b, '\n')
+	return b, nil
+}
+
+// buildPwshInitFile assembles a PowerShell-flavored version of the devbox
+// init file. PowerShell profiles can't parse POSIX `for`/`[ -r ... ]`
+// syntax, so it gets its own template, the same as fish.
+func (s *Shell) buildPwshInitFile(posthook string, hasFragments bool) ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteString("$env:ORIGINAL_PATH = $env:PATH\n")
+
+	if hasFragments {
+		buf.WriteString(`
+# Begin Devbox Pre-init Hook
+
+`)
+		buf.WriteString(pwshHookLoopSnippet(s.hookExt()))
+		buf.WriteString(`
+
+# End Devbox Pre-init Hook
+
+`)
+	}
+
+	// The PowerShell profile is allowed to not exist yet; there's just
+	// nothing to source in that case.
+	if initFile, err := os.ReadFile(s.initFile); err == nil {
+		initFile = bytes.TrimSpace(initFile)
+		if len(initFile) > 0 {
+			buf.Write(initFile)
+			buf.WriteString("\n")
+		}
+	}
+
+	if posthook != "" {
+		buf.WriteString(`
+# Begin Devbox Post-init Hook
+
+`)
+		buf.WriteString(posthook)
+		buf.WriteString(`
+
+# End Devbox Post-init Hook`)
+	}
+
+	b := buf.Bytes()
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return nil, nil
+	}
+	b = append(b, '\n')
+	return b, nil
+}
+
 func (s *Shell) writeHooks() error {
 	initContents, err := s.buildInitFile()
 	if err != nil {
@@ -192,7 +436,68 @@ func (s *Shell) ExecCommand() string {
 	case shKsh, shPosix:
 		return fmt.Sprintf(`exec /usr/bin/env ORIGINAL_PATH="%s" ENV="%s" %s `,
 			os.Getenv("PATH"), s.devboxInitFile, s.path)
+	case shFish:
+		// fish has no rcfile flag, so the init file must be sourced
+		// explicitly after fish starts up.
+		return fmt.Sprintf(`exec /usr/bin/env ORIGINAL_PATH="%s" %s -C 'source %s'`,
+			os.Getenv("PATH"), s.path, s.devboxInitFile)
+	case shPwsh:
+		return fmt.Sprintf(`exec /usr/bin/env ORIGINAL_PATH="%s" %s -NoExit -File "%s"`,
+			os.Getenv("PATH"), s.path, s.devboxInitFile)
 	default:
 		return "exec " + s.path
 	}
 }
+
+// RunCommand returns a command that runs cmd non-interactively inside s,
+// with the same pre-init and post-init hooks as ExecCommand; that command
+// exits with cmd's exit status. Unlike ExecCommand, it doesn't replace the current process,
+// so it's suitable for scripts and CI that need to run a single command
+// in a hook-augmented environment.
+func (s *Shell) RunCommand(cmd string) string {
+	return s.runCommand(cmd)
+}
+
+// RunCommandArgs is RunCommand for a command given as separate arguments
+// instead of a single already-quoted string.
+func (s *Shell) RunCommandArgs(args []string) string {
+	return s.runCommand(strings.Join(args, " "))
+}
+
+func (s *Shell) runCommand(cmd string) string {
+	if err := s.writeHooks(); err != nil || s.devboxInitFile == "" {
+		debug.Log("Failed to write shell pre-init and post-init hooks: %v", err)
+		return fmt.Sprintf("%s -c %q", s.path, cmd)
+	}
+
+	switch s.name {
+	case shBash:
+		// --rcfile (and therefore our hooks) is only honored by an
+		// interactive shell, so -i has to accompany -c here.
+		return fmt.Sprintf(`/usr/bin/env ORIGINAL_PATH="%s" %s --rcfile "%s" -i -c %q`,
+			os.Getenv("PATH"), s.path, s.devboxInitFile, cmd)
+	case shZsh:
+		// zsh only reads $ZDOTDIR/.zshrc for an interactive shell, so -i
+		// has to accompany -c here too.
+		return fmt.Sprintf(`/usr/bin/env ORIGINAL_PATH="%s" ZDOTDIR="%s" %s -i -c %q`,
+			os.Getenv("PATH"), filepath.Dir(s.devboxInitFile), s.path, cmd)
+	case shKsh, shPosix:
+		// Same story as bash/zsh: $ENV is only sourced by an interactive
+		// shell.
+		return fmt.Sprintf(`/usr/bin/env ORIGINAL_PATH="%s" ENV="%s" %s -i -c %q`,
+			os.Getenv("PATH"), s.devboxInitFile, s.path, cmd)
+	case shFish:
+		return fmt.Sprintf(`/usr/bin/env ORIGINAL_PATH="%s" %s -C 'source %s' -c %q`,
+			os.Getenv("PATH"), s.path, s.devboxInitFile, cmd)
+	case shPwsh:
+		// -File and -Command are mutually exclusive in pwsh: once -File
+		// is given, everything after it is passed as arguments to that
+		// script instead of being parsed as -Command. Dot-source the
+		// init file from inside a single -Command instead.
+		pwshCmd := fmt.Sprintf(". '%s'; %s", s.devboxInitFile, cmd)
+		return fmt.Sprintf(`/usr/bin/env ORIGINAL_PATH="%s" %s -Command %q`,
+			os.Getenv("PATH"), s.path, pwshCmd)
+	default:
+		return fmt.Sprintf("%s -c %q", s.path, cmd)
+	}
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 Jetpack Technologies Inc and contributors. All rights reserved.
+// Use of this source code is governed by the license in the LICENSE file.
+
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestShell(t *testing.T, n name, initFileContents string) *Shell {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+
+	initFile := filepath.Join(dir, "initrc")
+	if err := os.WriteFile(initFile, []byte(initFileContents), 0600); err != nil {
+		t.Fatalf("write init file: %v", err)
+	}
+	return &Shell{name: n, path: "/bin/" + string(n), initFile: initFile}
+}
+
+func TestBuildInitFileNoHooks(t *testing.T) {
+	s := newTestShell(t, shBash, "echo hello")
+	got, err := s.buildInitFile()
+	if err != nil {
+		t.Fatalf("buildInitFile: %v", err)
+	}
+	if got != nil {
+		t.Errorf("got %q, want nil when there are no hooks or fragments", got)
+	}
+}
+
+func TestBuildInitFilePerShell(t *testing.T) {
+	cases := []struct {
+		name          name
+		wantOriginal  string // empty if this shell doesn't set ORIGINAL_PATH in the init file itself
+		wantLoopPiece string
+	}{
+		{shBash, "", `for x in`},
+		{shFish, `set -x ORIGINAL_PATH`, `for x in`},
+		{shPwsh, `$env:ORIGINAL_PATH`, `Get-ChildItem`},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.name), func(t *testing.T) {
+			s := newTestShell(t, tc.name, "echo hello")
+			s.PostInitHook = "echo post"
+			if err := s.AddHookFragment("fragment", "echo fragment"); err != nil {
+				t.Fatalf("AddHookFragment: %v", err)
+			}
+
+			got, err := s.buildInitFile()
+			if err != nil {
+				t.Fatalf("buildInitFile: %v", err)
+			}
+
+			content := string(got)
+			if tc.wantOriginal != "" && !strings.Contains(content, tc.wantOriginal) {
+				t.Errorf("init file for %s missing ORIGINAL_PATH assignment %q:\n%s", tc.name, tc.wantOriginal, content)
+			}
+			if !strings.Contains(content, tc.wantLoopPiece) {
+				t.Errorf("init file for %s missing hook loop %q:\n%s", tc.name, tc.wantLoopPiece, content)
+			}
+			if !strings.Contains(content, "echo post") {
+				t.Errorf("init file for %s missing PostInitHook content:\n%s", tc.name, content)
+			}
+			if !strings.Contains(content, "echo hello") {
+				t.Errorf("init file for %s missing the user's original init file contents:\n%s", tc.name, content)
+			}
+		})
+	}
+}
+
+func TestBuildInitFileFoldsPreInitHook(t *testing.T) {
+	s := newTestShell(t, shBash, "echo hello")
+	s.PreInitHook = "echo legacy-pre-init"
+
+	got, err := s.buildInitFile()
+	if err != nil {
+		t.Fatalf("buildInitFile: %v", err)
+	}
+
+	if !strings.Contains(string(got), "for x in") {
+		t.Errorf("expected PreInitHook to be folded into the hook loop, got:\n%s", got)
+	}
+
+	fragment := filepath.Join(hooksDir(), legacyPreInitHookFragment+s.hookExt())
+	contents, err := os.ReadFile(fragment)
+	if err != nil {
+		t.Fatalf("expected PreInitHook to be written as a fragment at %s: %v", fragment, err)
+	}
+	if string(contents) != s.PreInitHook {
+		t.Errorf("fragment contents = %q, want %q", contents, s.PreInitHook)
+	}
+}
+
+func TestExecCommandPerShell(t *testing.T) {
+	cases := []struct {
+		name name
+		want []string // substrings that must all appear in the generated command
+	}{
+		{shBash, []string{"exec ", "--rcfile"}},
+		{shZsh, []string{"exec ", "ZDOTDIR="}},
+		{shKsh, []string{"exec ", "ENV="}},
+		{shFish, []string{"exec ", "-C 'source "}},
+		{shPwsh, []string{"exec ", "-NoExit", "-File"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.name), func(t *testing.T) {
+			s := newTestShell(t, tc.name, "echo hello")
+			got := s.ExecCommand()
+			for _, want := range tc.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("ExecCommand for %s missing %q, got: %s", tc.name, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRunCommandPerShell(t *testing.T) {
+	cases := []struct {
+		name name
+		want []string // substrings that must all appear in the generated command
+	}{
+		// bash and zsh only honor --rcfile/$ZDOTDIR/.zshrc for an
+		// interactive shell, and -c alone makes the shell
+		// non-interactive, so -i must be present alongside -c.
+		{shBash, []string{"--rcfile", " -i -c "}},
+		{shZsh, []string{"ZDOTDIR=", " -i -c "}},
+		{shKsh, []string{"ENV=", " -i -c "}},
+		{shFish, []string{"-C 'source ", " -c "}},
+		// -File and -Command are mutually exclusive in pwsh, so the
+		// init file must be dot-sourced from inside a single -Command
+		// instead of passed via -File.
+		{shPwsh, []string{"-Command", ". '"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.name), func(t *testing.T) {
+			s := newTestShell(t, tc.name, "echo hello")
+			got := s.RunCommand("echo cmd")
+			if strings.Contains(got, "-File") {
+				t.Errorf("RunCommand for %s should not use -File, got: %s", tc.name, got)
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("RunCommand for %s missing %q, got: %s", tc.name, want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestRunCommandArgsJoinsArgs(t *testing.T) {
+	s := newTestShell(t, shBash, "echo hello")
+	got := s.RunCommandArgs([]string{"echo", "a", "b"})
+	if !strings.Contains(got, "echo a b") {
+		t.Errorf("RunCommandArgs should join its args with spaces, got: %s", got)
+	}
+}